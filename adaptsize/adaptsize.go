@@ -3,13 +3,21 @@ package adaptsize
 import (
 	crand "crypto/rand"
 	"encoding/binary"
+	"encoding/hex"
 	"math"
 	"math/rand/v2"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/bootjp/adaptsize-go/adaptsize/admission"
+	"github.com/bootjp/adaptsize-go/adaptsize/store"
 )
 
+// Request is an alias of admission.Request so callers can keep using
+// adaptsize.Request; Cache satisfies admission.Decider via Admit.
+type Request = admission.Request
+
 // Options controls cache behavior.
 type Options struct {
 	CapacityBytes int64      // K: total capacity in bytes
@@ -17,8 +25,30 @@ type Options struct {
 	Alpha         float64    // EMA factor for rates r_i (default 0.5)
 	GridMin       int64      // min c in bytes (default 1 KiB)
 	GridMax       int64      // max c in bytes (default 64 MiB)
-	GridSteps     int        // number of c candidates, log-spaced (default 32)
 	Rand          *rand.Rand // RNG for admission; default seeded
+
+	// Policy chooses eviction victims for the storage layer backing
+	// Get/Set/Delete. Defaults to store.NewLRU().
+	Policy store.Policy
+
+	// SnapshotStore, if set, persists tuner state (c, prevR, the
+	// in-progress window) after every successful tuning round, and is
+	// consulted by Restore's callers to warm-start a new process.
+	SnapshotStore SnapshotStore
+
+	// Coordinator, if set, turns on fleet-wide tuning: each tuning round
+	// publishes this replica's window to the fleet and merges in peer
+	// contributions before choosing c. FleetWait bounds how long a round
+	// waits for peers (default 2s).
+	Coordinator Coordinator
+	FleetWait   time.Duration
+
+	// TunerParallelism bounds how many coarse c candidates searchBestC
+	// evaluates concurrently (default runtime.GOMAXPROCS(0)).
+	TunerParallelism int
+	// TunerTolerance is the relative (log-space) bracket width at which
+	// golden-section refinement stops (default 0.01).
+	TunerTolerance float64
 }
 
 type Cache struct {
@@ -27,15 +57,29 @@ type Cache struct {
 	// parameter c stored atomically
 	cBits atomic.Uint64
 
-	// tuning window
-	winMu   sync.Mutex
-	winReqs int
-	obs     map[string]*obs
-	prevR   map[string]float64 // EMA state
+	// sharded tuning window
+	win *window
+
+	// EMA state; prevRMu also guards Snapshot/Restore access to it since
+	// buildRates (called from the tuner goroutine or TuneOnce) mutates it
+	// outside of any window lock.
+	prevRMu sync.Mutex
+	prevR   map[string]float64
 
 	// background tuning
 	tuneCh chan struct{}
 	stopCh chan struct{}
+
+	// storage layer backing Get/Set/Delete
+	store *store.Store
+
+	// fleetRound is the monotonic round counter used when Options.Coordinator
+	// is set.
+	fleetRound atomic.Uint64
+
+	// replicaID identifies this Cache to a Coordinator so Fetch can
+	// exclude this replica's own prior Publish from what it returns.
+	replicaID string
 }
 
 type obs struct {
@@ -43,13 +87,12 @@ type obs struct {
 	cnt  int64
 }
 
-// Request holds metrics about a cache access. The caller is responsible for
-// determining whether it was a hit in their underlying cache.
-type Request struct {
-	Key       string
-	SizeBytes int64
-	Hit       bool
-}
+// Admit implements admission.Decider. It records req and, on a miss,
+// returns the admission decision. Cache.Request is the original, pre-
+// admission.Decider name for the same method.
+func (c *Cache) Admit(req Request) bool { return c.Request(req) }
+
+var _ admission.Decider = (*Cache)(nil)
 
 func defaultRandom() *rand.PCG {
 	var s1, s2 uint64
@@ -64,6 +107,18 @@ func defaultRandom() *rand.PCG {
 	return rand.NewPCG(s1, s2)
 }
 
+// newReplicaID generates an identifier unique enough to tag this Cache's
+// Coordinator publishes, so a fleet backend can exclude them on Fetch.
+func newReplicaID() string {
+	var b [16]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		// fallback
+		//nolint:gosec
+		binary.LittleEndian.PutUint64(b[:8], uint64(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(b[:])
+}
+
 // New constructs a cache and starts the background tuner.
 func New(opts Options) *Cache {
 	if opts.WindowN <= 0 {
@@ -78,20 +133,22 @@ func New(opts Options) *Cache {
 	if opts.GridMax <= opts.GridMin {
 		opts.GridMax = 64 << 20
 	} // 64 MiB
-	if opts.GridSteps <= 1 {
-		opts.GridSteps = 32
-	}
 	if opts.Rand == nil {
 		//nolint:gosec
 		opts.Rand = rand.New(defaultRandom())
 	}
+	if opts.Policy == nil {
+		opts.Policy = store.NewLRU()
+	}
 
 	c := &Cache{
-		opts:   opts,
-		obs:    make(map[string]*obs),
-		prevR:  make(map[string]float64),
-		tuneCh: make(chan struct{}, 1),
-		stopCh: make(chan struct{}),
+		opts:      opts,
+		win:       newWindow(defaultShardCount()),
+		prevR:     make(map[string]float64),
+		tuneCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		store:     store.New(opts.CapacityBytes, opts.Policy),
+		replicaID: newReplicaID(),
 	}
 	c.setC(256 << 10) // initial c = 256 KiB
 	go c.tuneLoop()
@@ -125,21 +182,7 @@ func (c *Cache) Request(req Request) bool {
 }
 
 func (c *Cache) record(key string, size int64) {
-	c.winMu.Lock()
-	o := c.obs[key]
-	if o == nil {
-		o = &obs{}
-		c.obs[key] = o
-	}
-	if size > 0 {
-		o.size = size
-	}
-	o.cnt++
-	c.winReqs++
-	need := c.winReqs >= c.opts.WindowN
-	c.winMu.Unlock()
-
-	if need {
+	if c.win.record(key, size, c.opts.WindowN) {
 		select {
 		case c.tuneCh <- struct{}{}:
 		default:
@@ -150,3 +193,30 @@ func (c *Cache) record(key string, size int64) {
 func (c *Cache) setC(v int64) {
 	c.cBits.Store(math.Float64bits(float64(v)))
 }
+
+// Get looks up key in the underlying store. The access is recorded as a
+// hit or a miss so the tuner's workload model stays in sync with what is
+// actually being served; callers do not need to call Request themselves.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	v, ok := c.store.Get(key)
+	c.Request(Request{Key: key, SizeBytes: int64(len(v)), Hit: ok})
+	return v, ok
+}
+
+// Set admits value for key according to the tuner's current admission
+// probability, storing it only if admitted. Any entries evicted to make
+// room are fed back into record so the tuner sees the churn they caused
+// as part of the same workload it tunes against.
+func (c *Cache) Set(key string, value []byte) {
+	if !c.Request(Request{Key: key, SizeBytes: int64(len(value)), Hit: false}) {
+		return
+	}
+	for _, ev := range c.store.Set(key, value) {
+		c.record(ev.Key, ev.Size)
+	}
+}
+
+// Delete removes key from the underlying store, if present.
+func (c *Cache) Delete(key string) {
+	c.store.Delete(key)
+}
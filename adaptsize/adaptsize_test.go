@@ -14,7 +14,6 @@ func newDeterministic(capacity int64) *Cache {
 		Alpha:         0.5,
 		GridMin:       1 << 10,
 		GridMax:       64 << 20,
-		GridSteps:     16,
 		Rand:          r,
 	})
 	return c
@@ -49,18 +48,14 @@ func TestRequestHitRecordsMetrics(t *testing.T) {
 	if c.Request(Request{Key: "a", SizeBytes: 800, Hit: true}) { // hit should not request admission
 		t.Fatal("expected hit to return false for admission")
 	}
-	c.winMu.Lock()
-	obs := c.obs["a"]
-	c.winMu.Unlock()
+	obs := c.win.obsFor("a")
 	if obs == nil || obs.cnt != 1 || obs.size != 800 {
 		t.Fatalf("hit metrics not recorded: %+v", obs)
 	}
 	if c.Request(Request{Key: "a", SizeBytes: 1200, Hit: true}) {
 		t.Fatal("expected hit to return false for admission")
 	}
-	c.winMu.Lock()
-	obs = c.obs["a"]
-	c.winMu.Unlock()
+	obs = c.win.obsFor("a")
 	if obs.cnt != 2 || obs.size != 1200 {
 		t.Fatalf("hit metrics not updated: %+v", obs)
 	}
@@ -73,7 +68,6 @@ func TestBackgroundTuningMovesC(t *testing.T) {
 		WindowN:       5000,
 		GridMin:       256,
 		GridMax:       8 << 20,
-		GridSteps:     12,
 		Rand:          r,
 	})
 	defer c.Close()
@@ -109,9 +103,7 @@ func TestRequestOversize(t *testing.T) {
 	if admit {
 		t.Fatal("expected oversize object not to be admitted")
 	}
-	c.winMu.Lock()
-	obs := c.obs["big"]
-	c.winMu.Unlock()
+	obs := c.win.obsFor("big")
 	if obs == nil || obs.cnt != 1 || obs.size != 2048 {
 		t.Fatalf("oversize request not recorded: %+v", obs)
 	}
@@ -140,8 +132,8 @@ func TestBuildRatesEMA(t *testing.T) {
 	}
 	rate := func(size int64) float64 {
 		for _, it := range items {
-			if it.s == size {
-				return it.r
+			if it.Size == size {
+				return it.Rate
 			}
 		}
 		return -1
@@ -161,6 +153,32 @@ func TestTuneOnceNoDataKeepsC(t *testing.T) {
 	}
 }
 
+func TestGetSetDelete(t *testing.T) {
+	c := newDeterministic(1 << 20)
+	defer c.Close()
+	c.cBits.Store(math.Float64bits(1 << 30)) // force admission so the test is deterministic
+
+	c.Set("a", []byte("hello"))
+	if v, ok := c.Get("a"); !ok || string(v) != "hello" {
+		t.Fatalf("expected a=hello, got %q ok=%v", v, ok)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a to be deleted")
+	}
+}
+
+func TestSetRespectsCapacity(t *testing.T) {
+	c := newDeterministic(10)
+	defer c.Close()
+	c.cBits.Store(math.Float64bits(1 << 30))
+
+	c.Set("big", make([]byte, 20)) // larger than capacity, never admitted
+	if _, ok := c.Get("big"); ok {
+		t.Fatal("expected oversize value not to be stored")
+	}
+}
+
 func randKey(prefix string, i int) string { return prefix + "-" + strconvI(i) }
 
 func strconvI(i int) string {
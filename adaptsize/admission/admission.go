@@ -0,0 +1,21 @@
+// Package admission defines the interface cache implementations use to
+// decide whether a miss should be let into the underlying store.
+package admission
+
+// Request describes a single cache access for admission purposes.
+type Request struct {
+	Key       string
+	SizeBytes int64
+	Hit       bool
+}
+
+// Decider decides whether a cache miss should be admitted into the
+// underlying store. Implementations typically track a model of the
+// workload (e.g. AdaptSize's size-aware admission parameter c) and adjust
+// it over time based on the requests they observe.
+type Decider interface {
+	// Admit records req and, if it was a miss (Hit=false), returns whether
+	// it should be admitted into the store. Hits are recorded but always
+	// return false, since there is nothing left to admit.
+	Admit(req Request) bool
+}
@@ -0,0 +1,223 @@
+package adaptsize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Coordinator lets a fleet of Cache replicas aggregate their per-window
+// rate observations before choosing a shared admission parameter c,
+// instead of each replica tuning against only the (noisier, possibly
+// LB-sharded) traffic it happens to see.
+//
+// Round numbers are per-Cache and strictly increasing; a Coordinator
+// should key contributions by round and drop anything published for a
+// round it no longer considers current, so a slow peer's late Publish
+// doesn't corrupt a later round. origin identifies the publishing
+// replica (Cache.replicaID): Fetch must exclude origin's own
+// contribution from what it returns, so a backend that shares state
+// with the publisher itself (e.g. a bus all replicas read, such as a
+// Kafka/Redis Streams topic) never hands a replica its own items back
+// on top of the local items aggregateFleet already has.
+type Coordinator interface {
+	// Publish shares this replica's per-window rate observations for
+	// round, tagged with origin.
+	Publish(round uint64, origin string, items []rateItem) error
+	// Fetch returns the contributions collected for round so far from
+	// every replica except origin.
+	Fetch(round uint64, origin string) ([]rateItem, error)
+}
+
+// fleetPollInterval is how often aggregateFleet re-polls Fetch while
+// waiting out Options.FleetWait.
+const fleetPollInterval = 20 * time.Millisecond
+
+// aggregateFleet publishes this replica's window to the fleet coordinator,
+// polls for peer contributions until Options.FleetWait has elapsed (it
+// always waits the full FleetWait, keeping the last successful Fetch), and
+// merges them
+// with its own items (summed by size, since replicas generally do not
+// share keys) before searchBestC runs. If Publish/Fetch fail, it falls
+// back to tuning on local items alone rather than blocking indefinitely.
+func (c *Cache) aggregateFleet(items []rateItem) ([]rateItem, float64) {
+	round := c.fleetRound.Add(1)
+	if err := c.opts.Coordinator.Publish(round, c.replicaID, items); err != nil {
+		return items, sumRates(items)
+	}
+
+	wait := c.opts.FleetWait
+	if wait <= 0 {
+		wait = 2 * time.Second
+	}
+	deadline := time.Now().Add(wait)
+	var peers []rateItem
+	for {
+		if got, err := c.opts.Coordinator.Fetch(round, c.replicaID); err == nil {
+			peers = got
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(fleetPollInterval)
+	}
+
+	merged := mergeBySize(items, peers)
+	return merged, sumRates(merged)
+}
+
+// mergeBySize combines rate items from multiple replicas, summing the
+// rates of items that share a size. Replicas are expected to observe
+// largely disjoint keys (e.g. sharded by an LB), so merging by key would
+// under-count; merging by size instead treats each replica's view as a
+// sample of the same overall size distribution.
+func mergeBySize(sets ...[]rateItem) []rateItem {
+	bySize := make(map[int64]float64)
+	for _, set := range sets {
+		for _, it := range set {
+			bySize[it.Size] += it.Rate
+		}
+	}
+	merged := make([]rateItem, 0, len(bySize))
+	for size, rate := range bySize {
+		merged = append(merged, rateItem{Size: size, Rate: rate})
+	}
+	return merged
+}
+
+func sumRates(items []rateItem) float64 {
+	total := 0.0
+	for _, it := range items {
+		total += it.Rate
+	}
+	return total
+}
+
+// HTTPCoordinator is a Coordinator backed by plain HTTP: each replica
+// runs its own HTTPCoordinatorServer as an inbox, and Publish pushes this
+// replica's items onto every one of Peers' inboxes so those replicas can
+// later read them. Fetch, in turn, pulls from Self -- this replica's own
+// inbox, the one every peer pushes onto -- rather than from Peers, so it
+// reads what peers deposited for this replica instead of echoing back
+// whatever this replica itself just pushed out. Rounds are monotonic, so
+// an inbox that has moved on to a later round simply returns nothing for
+// a stale Fetch.
+type HTTPCoordinator struct {
+	Self   string   // this replica's own inbox URL; peers Publish onto it
+	Peers  []string // peer inbox URLs this replica Publishes onto
+	Client *http.Client
+}
+
+// NewHTTPCoordinator constructs an HTTPCoordinator that publishes to
+// peers and fetches from self, the inbox server this replica runs for
+// peers to push onto.
+func NewHTTPCoordinator(self string, peers []string) *HTTPCoordinator {
+	return &HTTPCoordinator{Self: self, Peers: peers, Client: http.DefaultClient}
+}
+
+// fleetPayload is the wire format POSTed to an HTTPCoordinatorServer: items
+// tagged with the publishing replica's origin, so the server can later
+// exclude them from that same replica's Fetch.
+type fleetPayload struct {
+	Origin string     `json:"origin"`
+	Items  []rateItem `json:"items"`
+}
+
+func (h *HTTPCoordinator) Publish(round uint64, origin string, items []rateItem) error {
+	body, err := json.Marshal(fleetPayload{Origin: origin, Items: items})
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, peer := range h.Peers {
+		u := fmt.Sprintf("%s/adaptsize/fleet/%d", peer, round)
+		resp, err := h.Client.Post(u, "application/json", bytes.NewReader(body))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		resp.Body.Close()
+	}
+	return firstErr
+}
+
+func (h *HTTPCoordinator) Fetch(round uint64, origin string) ([]rateItem, error) {
+	u := fmt.Sprintf("%s/adaptsize/fleet/%d?exclude=%s", h.Self, round, url.QueryEscape(origin))
+	resp, err := h.Client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var items []rateItem
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return items, nil
+}
+
+// HTTPCoordinatorServer is the peer side of HTTPCoordinator: it accepts
+// pushed contributions for a round, keyed by publishing origin, and
+// serves them back on GET (excluding whichever origin is asking),
+// dropping everything from rounds older than the newest one seen so
+// late arrivals can't corrupt a round that has already moved on.
+type HTTPCoordinatorServer struct {
+	mu    sync.Mutex
+	round uint64
+	items map[string][]rateItem // origin -> items published for s.round
+}
+
+// NewHTTPCoordinatorServer constructs an empty HTTPCoordinatorServer.
+func NewHTTPCoordinatorServer() *HTTPCoordinatorServer {
+	return &HTTPCoordinatorServer{items: make(map[string][]rateItem)}
+}
+
+func (s *HTTPCoordinatorServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var round uint64
+	if _, err := fmt.Sscanf(r.URL.Path, "/adaptsize/fleet/%d", &round); err != nil {
+		http.Error(w, "bad round", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var payload fleetPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		if round > s.round {
+			s.round = round
+			s.items = make(map[string][]rateItem)
+		}
+		if round >= s.round {
+			s.items[payload.Origin] = payload.Items
+		}
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		exclude := r.URL.Query().Get("exclude")
+		s.mu.Lock()
+		var items []rateItem
+		if round == s.round {
+			for origin, its := range s.items {
+				if origin == exclude {
+					continue
+				}
+				items = append(items, its...)
+			}
+		}
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(items)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,210 @@
+package adaptsize
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memCoordinator is an in-memory Coordinator used to test fleet
+// aggregation without standing up real HTTP servers. Like a shared bus
+// (e.g. a Kafka/Redis Streams topic), every replica publishes to and
+// fetches from the same instance, so it must key contributions by origin
+// and exclude the caller's own origin from Fetch.
+type memCoordinator struct {
+	mu    sync.Mutex
+	round uint64
+	items map[string][]rateItem // origin -> items published for round
+}
+
+func (m *memCoordinator) Publish(round uint64, origin string, items []rateItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if round > m.round || m.items == nil {
+		m.round = round
+		m.items = make(map[string][]rateItem)
+	}
+	if round >= m.round {
+		m.items[origin] = items
+	}
+	return nil
+}
+
+func (m *memCoordinator) Fetch(round uint64, origin string) ([]rateItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if round != m.round {
+		return nil, nil
+	}
+	var out []rateItem
+	for o, its := range m.items {
+		if o == origin {
+			continue
+		}
+		out = append(out, its...)
+	}
+	return out, nil
+}
+
+func TestFleetAggregationMatchesSingleReplica(t *testing.T) {
+	const replicas = 4
+	const capacityPerReplica = int64(1<<20) / replicas
+
+	// Build one synthetic trace and shard it by key across replicas, the
+	// way an LB would.
+	trace := syntheticTrace(80_000, 42)
+	shards := make([][]Request, replicas)
+	for _, req := range trace {
+		idx := int(hashKey(req.Key)) % replicas
+		if idx < 0 {
+			idx += replicas
+		}
+		shards[idx] = append(shards[idx], req)
+	}
+
+	coord := &memCoordinator{}
+	replicaCaches := make([]*Cache, replicas)
+	var wg sync.WaitGroup
+	for i := 0; i < replicas; i++ {
+		replicaCaches[i] = New(Options{
+			CapacityBytes: capacityPerReplica,
+			WindowN:       1_000_000, // avoid background tuning firing mid-test
+			Coordinator:   coord,
+			FleetWait:     50 * time.Millisecond,
+		})
+	}
+	defer func() {
+		for _, c := range replicaCaches {
+			c.Close()
+		}
+	}()
+
+	wg.Add(replicas)
+	for i := 0; i < replicas; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for _, req := range shards[i] {
+				replicaCaches[i].Request(req)
+			}
+			replicaCaches[i].TuneOnce()
+		}(i)
+	}
+	wg.Wait()
+
+	// A single replica that sees the union of all traffic, tuned against
+	// the fleet's combined capacity.
+	single := New(Options{CapacityBytes: int64(1 << 20), WindowN: 1_000_000})
+	defer single.Close()
+	for _, req := range trace {
+		single.Request(req)
+	}
+	single.TuneOnce()
+
+	wantC := single.ParameterC()
+	for i, rc := range replicaCaches {
+		gotC := rc.ParameterC()
+		ratio := gotC / wantC
+		if ratio < 0.6 || ratio > 1.67 {
+			t.Fatalf("replica %d c=%.0f not close to single-replica c=%.0f (ratio %.2f)", i, gotC, wantC, ratio)
+		}
+	}
+}
+
+func TestHTTPCoordinatorRoundTrip(t *testing.T) {
+	srv1 := NewHTTPCoordinatorServer()
+	srv2 := NewHTTPCoordinatorServer()
+	ts1 := httptest.NewServer(srv1)
+	defer ts1.Close()
+	ts2 := httptest.NewServer(srv2)
+	defer ts2.Close()
+
+	// A's own inbox is srv1; it publishes onto srv2, B's inbox. Likewise
+	// B's own inbox is srv2, and it publishes onto srv1.
+	coordA := NewHTTPCoordinator(ts1.URL, []string{ts2.URL})
+	coordB := NewHTTPCoordinator(ts2.URL, []string{ts1.URL})
+
+	if err := coordA.Publish(1, "replicaA", []rateItem{{Size: 100, Rate: 5}}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := coordB.Publish(1, "replicaB", []rateItem{{Size: 200, Rate: 7}}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	gotFromB, err := coordA.Fetch(1, "replicaA")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(gotFromB) != 1 || gotFromB[0].Size != 200 || gotFromB[0].Rate != 7 {
+		t.Fatalf("unexpected peer items: %+v", gotFromB)
+	}
+
+	// A stale round (lower than what the server has since moved to) is
+	// dropped rather than merged into the new round.
+	if err := coordA.Publish(0, "replicaA", []rateItem{{Size: 999, Rate: 1}}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	stale, err := coordB.Fetch(1, "replicaB")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	for _, it := range stale {
+		if it.Size == 999 {
+			t.Fatalf("stale round-0 publish leaked into round 1: %+v", stale)
+		}
+	}
+}
+
+// TestHTTPCoordinatorServerExcludesOrigin verifies the shared-bus case a
+// standalone peer topology can't: a server that receives the same
+// origin's own publish must not hand it back to that origin's Fetch.
+func TestHTTPCoordinatorServerExcludesOrigin(t *testing.T) {
+	srv := NewHTTPCoordinatorServer()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	coord := NewHTTPCoordinator(ts.URL, []string{ts.URL})
+	if err := coord.Publish(1, "self", []rateItem{{Size: 100, Rate: 5}}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := coord.Publish(1, "other", []rateItem{{Size: 200, Rate: 7}}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	got, err := coord.Fetch(1, "self")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(got) != 1 || got[0].Size != 200 {
+		t.Fatalf("expected only the other origin's items, got %+v", got)
+	}
+}
+
+func TestMergeBySizeSumsAcrossReplicas(t *testing.T) {
+	a := []rateItem{{Size: 100, Rate: 1}, {Size: 200, Rate: 2}}
+	b := []rateItem{{Size: 100, Rate: 3}}
+	merged := mergeBySize(a, b)
+	var got100 float64
+	for _, it := range merged {
+		if it.Size == 100 {
+			got100 = it.Rate
+		}
+	}
+	if got100 != 4 {
+		t.Fatalf("expected size-100 rate to sum to 4, got %f", got100)
+	}
+}
+
+// hashKey is a small stand-in for a load balancer's shard function; it
+// only needs to be deterministic and roughly uniform for this test.
+func hashKey(key string) int64 {
+	var h int64 = 1469598103934665603
+	for i := 0; i < len(key); i++ {
+		h ^= int64(key[i])
+		h *= 1099511628211
+	}
+	if h < 0 {
+		h = -h
+	}
+	return h
+}
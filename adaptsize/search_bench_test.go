@@ -0,0 +1,146 @@
+package adaptsize
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// randomItems builds n rateItems over a realistic CDN-ish size
+// distribution (far fewer distinct sizes than items), used to benchmark
+// searchBestC at scale.
+func randomItems(n int, seed uint64) ([]rateItem, float64) {
+	r := rand.New(rand.NewPCG(seed, 0))
+	sizes := []int64{512, 1024, 4096, 16384, 65536, 262144, 1 << 20, 4 << 20}
+	items := make([]rateItem, n)
+	total := 0.0
+	for i := range items {
+		rate := 1 + r.Float64()*99
+		items[i] = rateItem{Size: sizes[r.IntN(len(sizes))], Rate: rate}
+		total += rate
+	}
+	return items, total
+}
+
+func BenchmarkSearchBestC(b *testing.B) {
+	for _, n := range []int{100_000, 1_000_000} {
+		items, total := randomItems(n, 1)
+		b.Run(fmt.Sprintf("items=%d", n), func(b *testing.B) {
+			c := New(Options{CapacityBytes: 8 << 30, GridMin: 1 << 10, GridMax: 64 << 20})
+			defer c.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.searchBestC(items, total)
+			}
+		})
+	}
+}
+
+// legacyGridSteps matches the default Options.GridSteps this package used
+// to expose before the parallel-anchor/golden-section search replaced the
+// full grid sweep; it's kept here only so searchBestCGrid below can stand
+// in for that old behavior in BenchmarkSearchBestCOldVsNew.
+const legacyGridSteps = 32
+
+// searchBestCGrid is the full log-spaced grid sweep searchBestC replaced.
+// It is not called anywhere outside this benchmark file: it exists solely
+// to give BenchmarkSearchBestCOldVsNew a baseline to compare the current
+// parallel-anchor/golden-section searchBestC against.
+func (c *Cache) searchBestCGrid(items []rateItem, totalReq float64) float64 {
+	steps := legacyGridSteps
+	grid := make([]float64, steps)
+	logMin := math.Log(float64(c.opts.GridMin))
+	logMax := math.Log(float64(c.opts.GridMax))
+	for i := 0; i < steps; i++ {
+		t := float64(i) / float64(steps-1)
+		grid[i] = math.Exp(logMin + t*(logMax-logMin))
+	}
+
+	bestC := math.Float64frombits(c.cBits.Load())
+	best := -1.0
+	for _, cand := range grid {
+		mu := solveMuGrid(items, cand, c.opts.CapacityBytes)
+		if mu <= 0 || math.IsNaN(mu) || math.IsInf(mu, 0) {
+			continue
+		}
+		hits := 0.0
+		for _, it := range items {
+			p := pinClosedFormGrid(it.Rate, mu, float64(it.Size), cand)
+			hits += it.Rate * p
+		}
+		ohr := hits / totalReq
+		if ohr > best {
+			best, bestC = ohr, cand
+		}
+	}
+	return bestC
+}
+
+func pinClosedFormGrid(ri, mu, si, c float64) float64 {
+	if ri <= 0 {
+		return 0
+	}
+	x := math.Exp(ri/mu) - 1.0
+	e := math.Exp(-si / c)
+	num := x * e
+	return num / (1.0 + num)
+}
+
+func solveMuGrid(items []rateItem, c float64, K int64) float64 {
+	if K <= 0 {
+		return math.NaN()
+	}
+	muLo := 1e-6
+	muHi := 1.0
+	for i := 0; i < 40; i++ {
+		if capBytesGrid(items, muHi, c) < float64(K) {
+			break
+		}
+		muHi *= 2
+	}
+	for i := 0; i < 60; i++ {
+		mid := 0.5 * (muLo + muHi)
+		if capBytesGrid(items, mid, c) > float64(K) {
+			muLo = mid
+		} else {
+			muHi = mid
+		}
+	}
+	return 0.5 * (muLo + muHi)
+}
+
+func capBytesGrid(items []rateItem, mu, c float64) float64 {
+	sum := 0.0
+	for _, it := range items {
+		sum += pinClosedFormGrid(it.Rate, mu, float64(it.Size), c) * float64(it.Size)
+	}
+	return sum
+}
+
+// BenchmarkSearchBestCOldVsNew compares the deleted full grid-search
+// searchBestCGrid against the current parallel-anchor/golden-section
+// searchBestC at the item counts the backlog item asked for (10^5, 10^6),
+// so the claimed speedup has a reproducible baseline instead of resting
+// on memory of the old numbers.
+func BenchmarkSearchBestCOldVsNew(b *testing.B) {
+	for _, n := range []int{100_000, 1_000_000} {
+		items, total := randomItems(n, 1)
+		b.Run(fmt.Sprintf("items=%d/grid", n), func(b *testing.B) {
+			c := New(Options{CapacityBytes: 8 << 30, GridMin: 1 << 10, GridMax: 64 << 20})
+			defer c.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.searchBestCGrid(items, total)
+			}
+		})
+		b.Run(fmt.Sprintf("items=%d/anchors", n), func(b *testing.B) {
+			c := New(Options{CapacityBytes: 8 << 30, GridMin: 1 << 10, GridMax: 64 << 20})
+			defer c.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.searchBestC(items, total)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package adaptsize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// snapshotMagic identifies a Cache snapshot; snapshotVersion lets Restore
+// reject formats it doesn't understand.
+const (
+	snapshotMagic   = "ASZ1"
+	snapshotVersion = 1
+)
+
+// SnapshotStore persists and retrieves the raw bytes produced by
+// Cache.Snapshot, so Cache.Restore can pick up where a previous process
+// left off. Implementations are responsible for atomicity: Save must not
+// leave a partially-written result visible to a concurrent Load.
+type SnapshotStore interface {
+	Save(data []byte) error
+	Load() ([]byte, error)
+}
+
+// FileSnapshotStore is a SnapshotStore backed by a single file. Save writes
+// to a temp file in the same directory and renames it into place, so a
+// concurrent Load never observes a partial write.
+type FileSnapshotStore struct {
+	Path string
+}
+
+// NewFileSnapshotStore constructs a FileSnapshotStore that persists to path.
+func NewFileSnapshotStore(path string) *FileSnapshotStore {
+	return &FileSnapshotStore{Path: path}
+}
+
+func (f *FileSnapshotStore) Save(data []byte) error {
+	dir := filepath.Dir(f.Path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.Path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, f.Path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+func (f *FileSnapshotStore) Load() ([]byte, error) {
+	return os.ReadFile(f.Path)
+}
+
+// Snapshot serializes the tuner's learned state (c, the per-key EMA rates,
+// and the in-progress observation window) to w in a versioned, CRC-
+// protected binary format.
+func (c *Cache) Snapshot(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+
+	var u64 [8]byte
+	binary.LittleEndian.PutUint64(u64[:], c.cBits.Load())
+	buf.Write(u64[:])
+
+	c.prevRMu.Lock()
+	prevR := make(map[string]float64, len(c.prevR))
+	for k, v := range c.prevR {
+		prevR[k] = v
+	}
+	c.prevRMu.Unlock()
+
+	obsSnap, winReqs := c.win.peek()
+
+	writeVarint(&buf, uint64(len(prevR)))
+	for k, v := range prevR {
+		writeString(&buf, k)
+		binary.LittleEndian.PutUint64(u64[:], math.Float64bits(v))
+		buf.Write(u64[:])
+	}
+
+	writeVarint(&buf, uint64(winReqs))
+	writeVarint(&buf, uint64(len(obsSnap)))
+	for k, o := range obsSnap {
+		writeString(&buf, k)
+		binary.LittleEndian.PutUint64(u64[:], uint64(o.size))
+		buf.Write(u64[:])
+		binary.LittleEndian.PutUint64(u64[:], uint64(o.cnt))
+		buf.Write(u64[:])
+	}
+
+	sum := crc32.ChecksumIEEE(buf.Bytes())
+	var sumBytes [4]byte
+	binary.LittleEndian.PutUint32(sumBytes[:], sum)
+	buf.Write(sumBytes[:])
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Restore loads tuner state previously written by Snapshot, replacing c,
+// prevR, and the in-progress observation window. A corrupt or truncated
+// snapshot (bad magic, version, or CRC) is rejected without modifying the
+// cache's live state.
+func (c *Cache) Restore(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) < len(snapshotMagic)+1+4 {
+		return fmt.Errorf("adaptsize: snapshot too short (%d bytes)", len(data))
+	}
+
+	body, wantSum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if gotSum := crc32.ChecksumIEEE(body); gotSum != wantSum {
+		return fmt.Errorf("adaptsize: snapshot CRC mismatch (got %x, want %x)", gotSum, wantSum)
+	}
+
+	br := bytes.NewReader(body)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return fmt.Errorf("adaptsize: bad snapshot magic")
+	}
+	version, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("adaptsize: unsupported snapshot version %d", version)
+	}
+
+	var u64 [8]byte
+	if _, err := io.ReadFull(br, u64[:]); err != nil {
+		return err
+	}
+	cBits := binary.LittleEndian.Uint64(u64[:])
+
+	nPrevR, err := readVarint(br)
+	if err != nil {
+		return err
+	}
+	prevR := make(map[string]float64, nPrevR)
+	for i := uint64(0); i < nPrevR; i++ {
+		k, err := readString(br)
+		if err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(br, u64[:]); err != nil {
+			return err
+		}
+		prevR[k] = math.Float64frombits(binary.LittleEndian.Uint64(u64[:]))
+	}
+
+	winReqs, err := readVarint(br)
+	if err != nil {
+		return err
+	}
+	nObs, err := readVarint(br)
+	if err != nil {
+		return err
+	}
+	obsMap := make(map[string]obs, nObs)
+	for i := uint64(0); i < nObs; i++ {
+		k, err := readString(br)
+		if err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(br, u64[:]); err != nil {
+			return err
+		}
+		size := int64(binary.LittleEndian.Uint64(u64[:]))
+		if _, err := io.ReadFull(br, u64[:]); err != nil {
+			return err
+		}
+		cnt := int64(binary.LittleEndian.Uint64(u64[:]))
+		obsMap[k] = obs{size: size, cnt: cnt}
+	}
+
+	c.prevRMu.Lock()
+	c.prevR = prevR
+	c.prevRMu.Unlock()
+	c.win.restore(obsMap, int64(winReqs))
+	c.cBits.Store(cBits)
+	return nil
+}
+
+// saveSnapshot persists the current tuner state via opts.SnapshotStore, if
+// configured. Errors are not fatal to tuning; they are swallowed here
+// because there is no caller in the background tuner loop to report them
+// to.
+func (c *Cache) saveSnapshot() {
+	if c.opts.SnapshotStore == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		return
+	}
+	_ = c.opts.SnapshotStore.Save(buf.Bytes())
+}
+
+func writeVarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.Write(tmp[:n])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeVarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func readVarint(r *bytes.Reader) (uint64, error) {
+	return binary.ReadUvarint(r)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := readVarint(r)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,163 @@
+package adaptsize
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	c := newDeterministic(1 << 20)
+	defer c.Close()
+	c.cBits.Store(math.Float64bits(12345))
+	c.prevR["a"] = 1.5
+	c.prevR["b"] = 2.5
+	c.win.record("a", 100, 1<<30) // seeds the "a" entry; cnt is fixed up below
+	c.win.obsFor("a").cnt = 3
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	c2 := newDeterministic(1 << 20)
+	defer c2.Close()
+	if err := c2.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if c2.ParameterC() != 12345 {
+		t.Fatalf("expected restored c=12345, got %f", c2.ParameterC())
+	}
+	if c2.prevR["a"] != 1.5 || c2.prevR["b"] != 2.5 {
+		t.Fatalf("unexpected prevR after restore: %+v", c2.prevR)
+	}
+	restoredA := c2.win.obsFor("a")
+	if restoredA == nil || restoredA.size != 100 || restoredA.cnt != 3 {
+		t.Fatalf("unexpected obs after restore: %+v", restoredA)
+	}
+	if _, winReqs := c2.win.peek(); winReqs != 1 {
+		t.Fatalf("expected winReqs=1, got %d", winReqs)
+	}
+}
+
+func TestRestoreRejectsCorruptSnapshot(t *testing.T) {
+	c := newDeterministic(1 << 20)
+	defer c.Close()
+	c.cBits.Store(math.Float64bits(777))
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	corrupt := buf.Bytes()
+	corrupt[len(corrupt)/2] ^= 0xFF
+
+	c2 := newDeterministic(1 << 20)
+	defer c2.Close()
+	c2.cBits.Store(math.Float64bits(999))
+	if err := c2.Restore(bytes.NewReader(corrupt)); err == nil {
+		t.Fatal("expected corrupt snapshot to be rejected")
+	}
+	if c2.ParameterC() != 999 {
+		t.Fatalf("corrupt restore must not clobber live state, got c=%f", c2.ParameterC())
+	}
+}
+
+func TestRestoreRejectsTruncatedSnapshot(t *testing.T) {
+	c2 := newDeterministic(1 << 20)
+	defer c2.Close()
+	c2.cBits.Store(math.Float64bits(999))
+	if err := c2.Restore(bytes.NewReader([]byte{1, 2, 3})); err == nil {
+		t.Fatal("expected truncated snapshot to be rejected")
+	}
+	if c2.ParameterC() != 999 {
+		t.Fatalf("truncated restore must not clobber live state, got c=%f", c2.ParameterC())
+	}
+}
+
+func TestFileSnapshotStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	fs := NewFileSnapshotStore(filepath.Join(dir, "snap.bin"))
+
+	if _, err := fs.Load(); err == nil {
+		t.Fatal("expected Load to fail before any Save")
+	}
+
+	want := []byte("some snapshot bytes")
+	if err := fs.Save(want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := fs.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Load returned %q, want %q", got, want)
+	}
+
+	// Save must not leave stray temp files behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one file in %s, got %d", dir, len(entries))
+	}
+}
+
+// syntheticTrace returns a repeatable key/size sequence, with keys drawn
+// uniformly from 2000 distinct keys and size fixed per key, used to
+// compare cold-start vs warm-start convergence.
+func syntheticTrace(n int, seed uint64) []Request {
+	r := rand.New(rand.NewPCG(seed, 0))
+	reqs := make([]Request, n)
+	for i := range reqs {
+		k := int(r.Int64N(2000))
+		size := int64(512 + (k%8)*1024)
+		reqs[i] = Request{Key: randKey("k", k), SizeBytes: size}
+	}
+	return reqs
+}
+
+func BenchmarkWarmVsColdStart(b *testing.B) {
+	trace := syntheticTrace(200_000, 7)
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := New(Options{CapacityBytes: 64 << 20, WindowN: 50_000})
+			for _, req := range trace {
+				c.Request(req)
+			}
+			c.TuneOnce()
+			c.Close()
+		}
+	})
+
+	b.Run("warm", func(b *testing.B) {
+		warm := New(Options{CapacityBytes: 64 << 20, WindowN: 50_000})
+		for _, req := range trace {
+			warm.Request(req)
+		}
+		warm.TuneOnce()
+		var snap bytes.Buffer
+		if err := warm.Snapshot(&snap); err != nil {
+			b.Fatalf("Snapshot: %v", err)
+		}
+		warm.Close()
+
+		for i := 0; i < b.N; i++ {
+			c := New(Options{CapacityBytes: 64 << 20, WindowN: 50_000})
+			if err := c.Restore(bytes.NewReader(snap.Bytes())); err != nil {
+				b.Fatalf("Restore: %v", err)
+			}
+			for _, req := range trace {
+				c.Request(req)
+			}
+			c.TuneOnce()
+			c.Close()
+		}
+	})
+}
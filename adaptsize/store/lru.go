@@ -0,0 +1,51 @@
+package store
+
+import "container/list"
+
+// LRU evicts the least-recently-used key.
+type LRU struct {
+	ll    *list.List
+	elems map[string]*list.Element
+}
+
+// NewLRU constructs an empty LRU policy.
+func NewLRU() *LRU {
+	return &LRU{
+		ll:    list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (p *LRU) Insert(key string, _ int64) {
+	if e, ok := p.elems[key]; ok {
+		p.ll.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.ll.PushFront(key)
+}
+
+func (p *LRU) Access(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *LRU) Remove(key string) {
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+	p.ll.Remove(e)
+	delete(p.elems, key)
+}
+
+func (p *LRU) Evict() (string, bool) {
+	e := p.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	key := e.Value.(string)
+	p.ll.Remove(e)
+	delete(p.elems, key)
+	return key, true
+}
@@ -0,0 +1,156 @@
+package store
+
+// s3entry tracks metadata for a single key tracked by S3FIFO.
+type s3entry struct {
+	key  string
+	size int64
+	freq int
+}
+
+// S3FIFO implements the S3-FIFO eviction policy (small/main/ghost queues),
+// which approximates LRU-like recency/frequency behavior using only FIFO
+// queues and a small per-entry access counter. See Yang et al., "FIFO
+// queues are all you need for cache eviction" (SOSP '23).
+//
+// New keys enter the small queue. A key promoted out of small with a
+// nonzero access count moves to main instead of being evicted; otherwise
+// its key (not its value) is remembered in a ghost queue so a later
+// reinsertion goes directly to main. Main is a plain FIFO with
+// second-chance reinsertion: an entry with a nonzero access count is
+// requeued with its counter decremented instead of being evicted, so an
+// entry can be requeued up to three times (the counter caps at 3).
+type S3FIFO struct {
+	smallLimit int64 // bytes; small queue target size, ~10% of capacity
+	ghostLimit int   // entries; ghost queue target size
+
+	small      []*s3entry
+	smallBytes int64
+	main       []*s3entry
+
+	ghost    []string
+	ghostSet map[string]struct{}
+
+	entries map[string]*s3entry
+	inSmall map[string]bool
+}
+
+// NewS3FIFO constructs an S3-FIFO policy sized for a store with the given
+// total capacity in bytes.
+func NewS3FIFO(capacityBytes int64) *S3FIFO {
+	limit := capacityBytes / 10
+	if limit <= 0 {
+		limit = 1
+	}
+	return &S3FIFO{
+		smallLimit: limit,
+		ghostLimit: 10_000,
+		ghostSet:   make(map[string]struct{}),
+		entries:    make(map[string]*s3entry),
+		inSmall:    make(map[string]bool),
+	}
+}
+
+func (p *S3FIFO) Insert(key string, size int64) {
+	e := &s3entry{key: key, size: size}
+	p.entries[key] = e
+	if _, wasGhost := p.ghostSet[key]; wasGhost {
+		p.removeGhost(key)
+		p.main = append(p.main, e)
+		return
+	}
+	p.small = append(p.small, e)
+	p.smallBytes += size
+	p.inSmall[key] = true
+}
+
+func (p *S3FIFO) Access(key string) {
+	if e, ok := p.entries[key]; ok && e.freq < 3 {
+		e.freq++
+	}
+}
+
+func (p *S3FIFO) Remove(key string) {
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	delete(p.entries, key)
+	if p.inSmall[key] {
+		p.small = removeEntry(p.small, key)
+		p.smallBytes -= e.size
+		delete(p.inSmall, key)
+		return
+	}
+	p.main = removeEntry(p.main, key)
+}
+
+func (p *S3FIFO) Evict() (string, bool) {
+	for {
+		if p.smallBytes > p.smallLimit && len(p.small) > 0 {
+			e := p.popSmall()
+			if e.freq > 0 {
+				e.freq = 0
+				p.main = append(p.main, e)
+				continue
+			}
+			delete(p.entries, e.key)
+			p.pushGhost(e.key)
+			return e.key, true
+		}
+		if len(p.main) == 0 {
+			if len(p.small) == 0 {
+				return "", false
+			}
+			e := p.popSmall()
+			delete(p.entries, e.key)
+			p.pushGhost(e.key)
+			return e.key, true
+		}
+		e := p.main[0]
+		p.main = p.main[1:]
+		if e.freq > 0 {
+			e.freq--
+			p.main = append(p.main, e)
+			continue
+		}
+		delete(p.entries, e.key)
+		return e.key, true
+	}
+}
+
+func (p *S3FIFO) popSmall() *s3entry {
+	e := p.small[0]
+	p.small = p.small[1:]
+	p.smallBytes -= e.size
+	delete(p.inSmall, e.key)
+	return e
+}
+
+func (p *S3FIFO) pushGhost(key string) {
+	p.ghost = append(p.ghost, key)
+	p.ghostSet[key] = struct{}{}
+	for len(p.ghost) > p.ghostLimit {
+		oldest := p.ghost[0]
+		p.ghost = p.ghost[1:]
+		delete(p.ghostSet, oldest)
+	}
+}
+
+func (p *S3FIFO) removeGhost(key string) {
+	delete(p.ghostSet, key)
+	for i, k := range p.ghost {
+		if k == key {
+			p.ghost = append(p.ghost[:i], p.ghost[i+1:]...)
+			break
+		}
+	}
+}
+
+func removeEntry(q []*s3entry, key string) []*s3entry {
+	for i, e := range q {
+		if e.key == key {
+			return append(q[:i], q[i+1:]...)
+		}
+	}
+	return q
+}
@@ -0,0 +1,134 @@
+// Package store provides a bounded, byte-accounted key/value store with a
+// pluggable eviction Policy. It is used by adaptsize.Cache to back Get/Set/
+// Delete, but has no dependency on the admission tuner itself.
+package store
+
+import "sync"
+
+// Policy is a pluggable eviction policy for a bounded store. Store holds
+// its own lock for the duration of every call into Policy, so
+// implementations do not need to be safe for concurrent use on their own.
+type Policy interface {
+	// Insert notifies the policy that key (size bytes) was just inserted.
+	Insert(key string, size int64)
+	// Access notifies the policy that key was read and found present.
+	Access(key string)
+	// Remove notifies the policy that key was deleted explicitly or is
+	// about to be overwritten. Keys returned by Evict are not passed to
+	// Remove, since Evict has already dropped them.
+	Remove(key string)
+	// Evict selects and removes the policy's next victim, or returns
+	// ("", false) if the policy has nothing left to evict.
+	Evict() (key string, ok bool)
+}
+
+// Evicted describes an entry that was removed from a Store to make room
+// for a new Set.
+type Evicted struct {
+	Key  string
+	Size int64
+}
+
+// Store is a bounded, byte-accounted key/value store. Capacity is enforced
+// by evicting keys chosen by Policy until new inserts fit.
+type Store struct {
+	mu       sync.Mutex
+	capacity int64
+	used     int64
+	data     map[string][]byte
+	sizes    map[string]int64
+	policy   Policy
+}
+
+// New constructs a Store bounded to capacity bytes, using policy to choose
+// eviction victims.
+func New(capacity int64, policy Policy) *Store {
+	return &Store{
+		capacity: capacity,
+		data:     make(map[string][]byte),
+		sizes:    make(map[string]int64),
+		policy:   policy,
+	}
+}
+
+// Get returns the value stored for key, if present.
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	if ok {
+		s.policy.Access(key)
+	}
+	return v, ok
+}
+
+// Set inserts value for key, evicting existing entries chosen by Policy
+// until it fits within capacity. It returns the entries evicted to make
+// room, if any. Values larger than the store's capacity are rejected
+// outright and leave the store unchanged.
+func (s *Store) Set(key string, value []byte) []Evicted {
+	size := int64(len(value))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size > s.capacity {
+		return nil
+	}
+
+	if old, ok := s.sizes[key]; ok {
+		s.used -= old
+		delete(s.data, key)
+		delete(s.sizes, key)
+		s.policy.Remove(key)
+	}
+
+	var out []Evicted
+	for s.used+size > s.capacity {
+		vk, ok := s.policy.Evict()
+		if !ok {
+			break
+		}
+		vsz, ok := s.sizes[vk]
+		if !ok {
+			continue
+		}
+		s.used -= vsz
+		delete(s.data, vk)
+		delete(s.sizes, vk)
+		out = append(out, Evicted{Key: vk, Size: vsz})
+	}
+
+	s.data[key] = value
+	s.sizes[key] = size
+	s.used += size
+	s.policy.Insert(key, size)
+	return out
+}
+
+// Delete removes key, if present.
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sz, ok := s.sizes[key]
+	if !ok {
+		return
+	}
+	s.used -= sz
+	delete(s.data, key)
+	delete(s.sizes, key)
+	s.policy.Remove(key)
+}
+
+// Len returns the number of entries currently stored.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data)
+}
+
+// Used returns the number of bytes currently stored.
+func (s *Store) Used() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
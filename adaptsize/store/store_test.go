@@ -0,0 +1,104 @@
+package store
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStoreCapacityAccounting(t *testing.T) {
+	s := New(100, NewLRU())
+	s.Set("a", make([]byte, 40))
+	s.Set("b", make([]byte, 40))
+	if got := s.Used(); got != 80 {
+		t.Fatalf("expected used=80, got %d", got)
+	}
+	// c doesn't fit without evicting a or b.
+	evicted := s.Set("c", make([]byte, 40))
+	if len(evicted) != 1 {
+		t.Fatalf("expected exactly one eviction, got %d", len(evicted))
+	}
+	if got := s.Used(); got != 80 {
+		t.Fatalf("expected used=80 after eviction, got %d", got)
+	}
+	if _, ok := s.Get(evicted[0].Key); ok {
+		t.Fatalf("evicted key %q still present", evicted[0].Key)
+	}
+}
+
+func TestStoreRejectsOversizeValue(t *testing.T) {
+	s := New(10, NewLRU())
+	evicted := s.Set("big", make([]byte, 20))
+	if evicted != nil {
+		t.Fatalf("expected no eviction for a rejected insert, got %+v", evicted)
+	}
+	if _, ok := s.Get("big"); ok {
+		t.Fatal("oversize value should not have been stored")
+	}
+}
+
+func TestStoreDelete(t *testing.T) {
+	s := New(100, NewLRU())
+	s.Set("a", make([]byte, 10))
+	s.Delete("a")
+	if _, ok := s.Get("a"); ok {
+		t.Fatal("expected a to be deleted")
+	}
+	if got := s.Used(); got != 0 {
+		t.Fatalf("expected used=0 after delete, got %d", got)
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	s := New(30, NewLRU())
+	s.Set("a", make([]byte, 10))
+	s.Set("b", make([]byte, 10))
+	s.Set("c", make([]byte, 10))
+	// touch a so it is no longer the least-recently-used.
+	s.Get("a")
+	evicted := s.Set("d", make([]byte, 10))
+	if len(evicted) != 1 || evicted[0].Key != "b" {
+		t.Fatalf("expected b to be evicted, got %+v", evicted)
+	}
+}
+
+func TestS3FIFOKeepsFrequentlyAccessedKeys(t *testing.T) {
+	s := New(100, NewS3FIFO(100))
+	for i := 0; i < 9; i++ {
+		s.Set(keyFor(i), make([]byte, 10))
+	}
+	// access key 0 repeatedly so that, when eviction first scans small
+	// (already past its 10%-of-capacity target), it is moved to main
+	// instead of being evicted.
+	for i := 0; i < 3; i++ {
+		s.Get(keyFor(0))
+	}
+	// push enough new inserts to force eviction out of small.
+	for i := 9; i < 20; i++ {
+		s.Set(keyFor(i), make([]byte, 10))
+	}
+	if _, ok := s.Get(keyFor(0)); !ok {
+		t.Fatal("expected frequently accessed key to survive eviction")
+	}
+}
+
+func TestStoreConcurrentGetSet(t *testing.T) {
+	s := New(1<<20, NewLRU())
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := keyFor(i % 10)
+			s.Set(k, make([]byte, 100))
+			s.Get(k)
+		}(i)
+	}
+	wg.Wait()
+	if s.Len() > 10 {
+		t.Fatalf("expected at most 10 distinct keys, got %d", s.Len())
+	}
+}
+
+func keyFor(i int) string {
+	return string([]byte{'k', byte('0' + i/10), byte('0' + i%10)})
+}
@@ -1,8 +1,9 @@
-
 package adaptsize
 
 import (
 	"math"
+	"runtime"
+	"sync"
 )
 
 // background tuner loop
@@ -10,20 +11,24 @@ func (c *Cache) tuneLoop() {
 	for {
 		select {
 		case <-c.tuneCh:
-			// snapshot window
-			c.winMu.Lock()
-			snap := make(map[string]obs, len(c.obs))
-			for k, v := range c.obs { snap[k] = *v }
-			c.obs = make(map[string]*obs)
-			c.winReqs = 0
-			c.winMu.Unlock()
-
-			if len(snap) == 0 { continue }
+			snap := c.win.snapshot()
+			if len(snap) == 0 {
+				continue
+			}
 			items, totalReq := c.buildRates(snap)
-			if len(items) == 0 { continue }
+			if len(items) == 0 {
+				continue
+			}
+			if c.opts.Coordinator != nil {
+				items, totalReq = c.aggregateFleet(items)
+				if len(items) == 0 {
+					continue
+				}
+			}
 			bestC := c.searchBestC(items, totalReq)
 			if !math.IsNaN(bestC) && !math.IsInf(bestC, 0) {
 				c.cBits.Store(math.Float64bits(bestC))
+				c.saveSnapshot()
 			}
 		case <-c.stopCh:
 			return
@@ -31,113 +36,258 @@ func (c *Cache) tuneLoop() {
 	}
 }
 
-type rateItem struct{ s int64; r float64 }
+// rateItem is a single size's EMA request rate. Fields are exported so a
+// Coordinator can ship them between replicas (e.g. as JSON) without a
+// separate wire type.
+type rateItem struct {
+	Size int64
+	Rate float64
+}
 
 func (c *Cache) buildRates(snap map[string]obs) ([]rateItem, float64) {
 	items := make([]rateItem, 0, len(snap))
 	total := 0.0
+	c.prevRMu.Lock()
+	defer c.prevRMu.Unlock()
 	for k, o := range snap {
-		if o.size <= 0 { continue }
+		if o.size <= 0 {
+			continue
+		}
 		prev := c.prevR[k]
 		rate := c.opts.Alpha*float64(o.cnt) + (1.0-c.opts.Alpha)*prev
 		c.prevR[k] = rate
-		items = append(items, rateItem{s: o.size, r: rate})
+		items = append(items, rateItem{Size: o.size, Rate: rate})
 		total += rate
 	}
 	return items, total
 }
 
+// coarseAnchors is the number of log-spaced candidates evaluated in
+// parallel before golden-section refinement narrows in on the maximum.
+// refinementSteps bounds the refinement pass once the maximum is
+// bracketed.
+const (
+	coarseAnchors   = 8
+	refinementSteps = 12
+	goldenRatio     = 0.6180339887498949
+)
+
+// searchBestC finds the c that maximizes the modeled OHR for items. The
+// OHR-vs-log(c) curve is empirically unimodal on realistic workloads, so
+// rather than serially evaluating every candidate of a fixed log-spaced
+// grid (the original approach), this does a coarse parallel pass over a handful of
+// log-spaced anchors, then refines between the two anchors that bracket
+// the best of them via golden-section search in log space.
 func (c *Cache) searchBestC(items []rateItem, totalReq float64) float64 {
-	// log-spaced grid for c
-	steps := c.opts.GridSteps
-	grid := make([]float64, steps)
+	if totalReq <= 0 {
+		return math.Float64frombits(c.cBits.Load())
+	}
+
 	logMin := math.Log(float64(c.opts.GridMin))
 	logMax := math.Log(float64(c.opts.GridMax))
-	for i := 0; i < steps; i++ {
-		t := float64(i) / float64(steps-1)
-		grid[i] = math.Exp(logMin + t*(logMax-logMin))
-	}
-
-	bestC := math.Float64frombits(c.cBits.Load())
-	best := -1.0
-	for _, cand := range grid {
-		mu := solveMu(items, cand, c.opts.CapacityBytes)
-		if mu <= 0 || math.IsNaN(mu) || math.IsInf(mu, 0) { continue }
-		hits := 0.0
-		for _, it := range items {
-			p := pinClosedForm(it.r, mu, float64(it.s), cand)
-			hits += it.r * p
+	anchors := make([]float64, coarseAnchors)
+	for i := range anchors {
+		t := float64(i) / float64(coarseAnchors-1)
+		anchors[i] = math.Exp(logMin + t*(logMax-logMin))
+	}
+
+	parallelism := c.opts.TunerParallelism
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	ohrs := make([]float64, len(anchors))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	// items is read-only here and shared by every goroutine; no per-
+	// candidate copy is needed.
+	for i, cand := range anchors {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cand float64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ohrs[i] = c.evalOHR(items, totalReq, cand)
+		}(i, cand)
+	}
+	wg.Wait()
+
+	// NaN candidates are skipped outright: every comparison against NaN
+	// is false, so if bestIdx ever landed on one, no later (valid) ohr
+	// could ever replace it.
+	bestIdx := -1
+	for i, ohr := range ohrs {
+		if math.IsNaN(ohr) {
+			continue
+		}
+		if bestIdx == -1 || ohr > ohrs[bestIdx] {
+			bestIdx = i
+		}
+	}
+	if bestIdx == -1 || ohrs[bestIdx] < 0 {
+		return math.Float64frombits(c.cBits.Load())
+	}
+
+	lo, hi := anchors[bestIdx], anchors[bestIdx]
+	if bestIdx > 0 {
+		lo = anchors[bestIdx-1]
+	}
+	if bestIdx < len(anchors)-1 {
+		hi = anchors[bestIdx+1]
+	}
+
+	tol := c.opts.TunerTolerance
+	if tol <= 0 {
+		tol = 0.01
+	}
+	refined := c.goldenSectionRefine(items, totalReq, lo, hi, tol)
+	if math.IsNaN(refined) {
+		return anchors[bestIdx]
+	}
+	return refined
+}
+
+// goldenSectionRefine searches for the c in [lo, hi] (lo<=hi) that
+// maximizes evalOHR, working in log space since c candidates are
+// log-spaced. It runs for at most refinementSteps iterations or until the
+// bracket shrinks below the relative tolerance tol.
+func (c *Cache) goldenSectionRefine(items []rateItem, totalReq, lo, hi, tol float64) float64 {
+	if lo <= 0 || hi < lo {
+		return math.NaN()
+	}
+	if hi == lo {
+		return lo
+	}
+	logLo, logHi := math.Log(lo), math.Log(hi)
+	x1 := logHi - goldenRatio*(logHi-logLo)
+	x2 := logLo + goldenRatio*(logHi-logLo)
+	f1 := c.evalOHR(items, totalReq, math.Exp(x1))
+	f2 := c.evalOHR(items, totalReq, math.Exp(x2))
+
+	for i := 0; i < refinementSteps && (logHi-logLo) > tol; i++ {
+		if f1 < f2 {
+			logLo = x1
+			x1, f1 = x2, f2
+			x2 = logLo + goldenRatio*(logHi-logLo)
+			f2 = c.evalOHR(items, totalReq, math.Exp(x2))
+		} else {
+			logHi = x2
+			x2, f2 = x1, f1
+			x1 = logHi - goldenRatio*(logHi-logLo)
+			f1 = c.evalOHR(items, totalReq, math.Exp(x1))
 		}
-		ohr := hits / totalReq
-		if ohr > best {
-			best, bestC = ohr, cand
+	}
+	if f1 > f2 {
+		return math.Exp(x1)
+	}
+	return math.Exp(x2)
+}
+
+// evalOHR computes the modeled OHR for candidate c: it solves for the
+// per-item admission pressure μ that fills CapacityBytes, then sums the
+// resulting hit rate across items. exp(-size/c) is cached per distinct
+// size so the bracket doubling and 60-step bisection inside solveMu, and the final
+// hit-rate pass, don't recompute math.Exp for every item on every
+// iteration — workloads typically have far fewer distinct sizes than
+// items.
+func (c *Cache) evalOHR(items []rateItem, totalReq, cand float64) float64 {
+	expBySize := sizeExpCache(items, cand)
+	mu := solveMu(items, expBySize, c.opts.CapacityBytes)
+	if mu <= 0 || math.IsNaN(mu) || math.IsInf(mu, 0) {
+		return -1
+	}
+	hits := 0.0
+	for _, it := range items {
+		p := pinClosedForm(it.Rate, mu, expBySize[it.Size])
+		hits += it.Rate * p
+	}
+	return hits / totalReq
+}
+
+func sizeExpCache(items []rateItem, c float64) map[int64]float64 {
+	cache := make(map[int64]float64, len(items))
+	for _, it := range items {
+		if _, ok := cache[it.Size]; !ok {
+			cache[it.Size] = math.Exp(-float64(it.Size) / c)
 		}
 	}
-	return bestC
+	return cache
 }
 
-// P_in(i) closed form.
-func pinClosedForm(ri, mu float64, si float64, c float64) float64 {
-	if ri <= 0 { return 0 }
-	x := math.Exp(ri/mu) - 1.0
-	e := math.Exp(-si / c)
-	num := x * e
-	return num / (1.0 + num)
+// P_in(i) closed form; e is the precomputed exp(-size_i/c). Written in
+// terms of exp(-z) rather than exp(z) (z=ri/mu, always >= 0 here) so it
+// saturates cleanly to 1 as z grows instead of computing Inf/Inf: exp(z)
+// can overflow to +Inf for a plausible z (e.g. mu driven down toward
+// muLo by solveMu), but exp(-z) only ever underflows to 0, which is
+// exact. An e of 0 (size so large relative to c that it underflows)
+// means this item is essentially never admitted regardless of z, so
+// it's handled separately rather than falling out of the algebra as a
+// 0/0.
+func pinClosedForm(ri, mu, e float64) float64 {
+	if ri <= 0 || e <= 0 {
+		return 0
+	}
+	ez := math.Exp(-ri / mu)
+	num := (1.0 - ez) * e
+	denom := ez + (1.0-ez)*e
+	return num / denom
 }
 
 // Solve μ: sum P_in(i)*s_i = K via monotone binary search.
-func solveMu(items []rateItem, c float64, K int64) float64 {
-	if K <= 0 { return math.NaN() }
+func solveMu(items []rateItem, expBySize map[int64]float64, K int64) float64 {
+	if K <= 0 {
+		return math.NaN()
+	}
 	muLo := 1e-6
 	muHi := 1.0
 	for i := 0; i < 40; i++ {
-		if capBytes(items, muHi, c) < float64(K) { break }
+		if capBytes(items, muHi, expBySize) < float64(K) {
+			break
+		}
 		muHi *= 2
 	}
 	for i := 0; i < 60; i++ {
-		mid := 0.5*(muLo+muHi)
-		sum := capBytes(items, mid, c)
+		mid := 0.5 * (muLo + muHi)
+		sum := capBytes(items, mid, expBySize)
 		if sum > float64(K) {
 			muLo = mid
 		} else {
 			muHi = mid
 		}
 	}
-	return 0.5*(muLo+muHi)
+	return 0.5 * (muLo + muHi)
 }
 
-func capBytes(items []rateItem, mu, c float64) float64 {
+func capBytes(items []rateItem, mu float64, expBySize map[int64]float64) float64 {
 	sum := 0.0
 	for _, it := range items {
-		p := pinClosedForm(it.r, mu, float64(it.s), c)
-		sum += p * float64(it.s)
+		p := pinClosedForm(it.Rate, mu, expBySize[it.Size])
+		sum += p * float64(it.Size)
 	}
 	return sum
 }
 
-
-/*
-TuneOnce runs one tuning round synchronously:
-- snapshots the window
-- recomputes EMA rates
-- grid-searches c and solves μ per candidate
-- installs the best c
-It is safe to call concurrently with Get/Set.
-*/
+// TuneOnce runs one tuning round synchronously: it snapshots the window,
+// recomputes EMA rates, merges in fleet contributions if a Coordinator is
+// configured, searches for the best c, and installs it. It is safe to call
+// concurrently with Get/Set.
 func (c *Cache) TuneOnce() {
-	// snapshot window
-	c.winMu.Lock()
-	snap := make(map[string]obs, len(c.obs))
-	for k, v := range c.obs { snap[k] = *v }
-	c.obs = make(map[string]*obs)
-	c.winReqs = 0
-	c.winMu.Unlock()
-
-	if len(snap) == 0 { return }
+	snap := c.win.snapshot()
+	if len(snap) == 0 {
+		return
+	}
 	items, totalReq := c.buildRates(snap)
-	if len(items) == 0 || totalReq == 0 { return }
+	if len(items) == 0 || totalReq == 0 {
+		return
+	}
+	if c.opts.Coordinator != nil {
+		items, totalReq = c.aggregateFleet(items)
+		if len(items) == 0 {
+			return
+		}
+	}
 	bestC := c.searchBestC(items, totalReq)
 	if !math.IsNaN(bestC) && !math.IsInf(bestC, 0) {
 		c.cBits.Store(math.Float64bits(bestC))
+		c.saveSnapshot()
 	}
 }
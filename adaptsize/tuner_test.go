@@ -0,0 +1,76 @@
+package adaptsize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSizeExpCacheHasOneEntryPerDistinctSize(t *testing.T) {
+	items := []rateItem{{Size: 100, Rate: 1}, {Size: 100, Rate: 2}, {Size: 200, Rate: 3}}
+	cache := sizeExpCache(items, 500)
+	if len(cache) != 2 {
+		t.Fatalf("expected 2 distinct sizes, got %d", len(cache))
+	}
+	if cache[100] != math.Exp(-100.0/500) || cache[200] != math.Exp(-200.0/500) {
+		t.Fatalf("unexpected cache contents: %+v", cache)
+	}
+}
+
+func TestSearchBestCMatchesBruteForceGrid(t *testing.T) {
+	c := newDeterministic(1 << 20)
+	defer c.Close()
+	items := []rateItem{
+		{Size: 1 << 10, Rate: 50},
+		{Size: 1 << 16, Rate: 10},
+		{Size: 1 << 20, Rate: 2},
+	}
+	total := 0.0
+	for _, it := range items {
+		total += it.Rate
+	}
+
+	got := c.searchBestC(items, total)
+
+	// Brute-force an independent reference over a dense grid, using the
+	// same evalOHR so this only validates that parallel-anchor +
+	// golden-section finds a candidate close to the true maximum, not
+	// that evalOHR itself is correct.
+	bestRef, bestOHR := 0.0, -1.0
+	logMin, logMax := math.Log(float64(c.opts.GridMin)), math.Log(float64(c.opts.GridMax))
+	const denseSteps = 500
+	for i := 0; i < denseSteps; i++ {
+		tt := float64(i) / float64(denseSteps-1)
+		cand := math.Exp(logMin + tt*(logMax-logMin))
+		ohr := c.evalOHR(items, total, cand)
+		if ohr > bestOHR {
+			bestOHR, bestRef = ohr, cand
+		}
+	}
+
+	ratio := got / bestRef
+	if ratio < 0.5 || ratio > 2.0 {
+		t.Fatalf("searchBestC=%.0f too far from brute-force best=%.0f (ratio %.2f)", got, bestRef, ratio)
+	}
+}
+
+func TestGoldenSectionRefineRejectsEmptyBracket(t *testing.T) {
+	c := newDeterministic(1 << 20)
+	defer c.Close()
+	items := []rateItem{{Size: 1024, Rate: 10}}
+	if got := c.goldenSectionRefine(items, 10, 100, 1, 0.01); !math.IsNaN(got) {
+		t.Fatalf("expected NaN for an inverted bracket, got %f", got)
+	}
+}
+
+func TestSearchBestCWithTunerParallelismOne(t *testing.T) {
+	c := New(Options{
+		CapacityBytes:    1 << 20,
+		TunerParallelism: 1,
+	})
+	defer c.Close()
+	items, total := randomItems(1000, 5)
+	got := c.searchBestC(items, total)
+	if math.IsNaN(got) || math.IsInf(got, 0) || got <= 0 {
+		t.Fatalf("expected a valid c, got %f", got)
+	}
+}
@@ -0,0 +1,176 @@
+package adaptsize
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// obsShard is one stripe of the sharded observation window. Splitting the
+// window across shards lets concurrent Request calls for different keys
+// update disjoint maps under disjoint locks, instead of all serializing
+// behind a single mutex. cnt is this shard's own request counter since
+// the last reset; writes to it always happen under mu alongside the obs
+// map they track, but it's an atomic so total can read every shard's
+// counter without taking any shard's lock.
+type obsShard struct {
+	mu  sync.Mutex
+	obs map[string]*obs
+	cnt atomic.Int64
+}
+
+// window is the sharded replacement for a single map[string]*obs plus its
+// guarding mutex. Shard count is fixed at construction and is always a
+// power of two so shardIndex can mask instead of mod.
+type window struct {
+	shards []obsShard
+	seed   maphash.Seed
+}
+
+// newWindow constructs a window with n shards, rounded up to the next
+// power of two.
+func newWindow(n int) *window {
+	n = nextPow2(n)
+	w := &window{
+		shards: make([]obsShard, n),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range w.shards {
+		w.shards[i].obs = make(map[string]*obs)
+	}
+	return w
+}
+
+func (w *window) shardIndex(key string) int {
+	return int(maphash.String(w.seed, key) & uint64(len(w.shards)-1))
+}
+
+// record bumps key's count (and, if size is known, its tracked size) and
+// reports whether the window has reached Options.WindowN requests since
+// the last snapshot. sh.cnt is bumped under the same lock as the obs map
+// (so it can never race with snapshot/restore's reset of that same
+// shard), but it's an atomic rather than a plain field so total can read
+// every shard's counter without taking any shard's lock at all.
+func (w *window) record(key string, size int64, windowN int) (needTune bool) {
+	sh := &w.shards[w.shardIndex(key)]
+	sh.mu.Lock()
+	o := sh.obs[key]
+	if o == nil {
+		o = &obs{}
+		sh.obs[key] = o
+	}
+	if size > 0 {
+		o.size = size
+	}
+	o.cnt++
+	sh.cnt.Add(1)
+	sh.mu.Unlock()
+
+	return w.total() >= int64(windowN)
+}
+
+// total sums every shard's request counter. It is read-only, so unlike
+// the single shared counter this replaced, concurrent record calls on
+// different shards never block each other here.
+func (w *window) total() int64 {
+	var sum int64
+	for i := range w.shards {
+		sum += w.shards[i].cnt.Load()
+	}
+	return sum
+}
+
+// snapshot merges every shard's observations into a single flat map (keys
+// never collide across shards, so this is a plain merge), resets each
+// shard, and zeroes the window-request counter. It locks one shard at a
+// time rather than all of them together, so a snapshot in progress never
+// blocks unrelated shards' Request calls for long.
+func (w *window) snapshot() map[string]obs {
+	merged := make(map[string]obs)
+	for i := range w.shards {
+		sh := &w.shards[i]
+		sh.mu.Lock()
+		for k, v := range sh.obs {
+			merged[k] = *v
+		}
+		sh.obs = make(map[string]*obs)
+		sh.cnt.Store(0)
+		sh.mu.Unlock()
+	}
+	return merged
+}
+
+// peek returns every tracked observation and the current window-request
+// count without resetting anything, unlike snapshot. It is used by
+// Cache.Snapshot, which must not disturb the live window it's persisting.
+func (w *window) peek() (map[string]obs, int64) {
+	merged := make(map[string]obs)
+	for i := range w.shards {
+		sh := &w.shards[i]
+		sh.mu.Lock()
+		for k, v := range sh.obs {
+			merged[k] = *v
+		}
+		sh.mu.Unlock()
+	}
+	return merged, w.total()
+}
+
+// restore replaces the window's contents with obsMap and sets the
+// window-request counter to winReqs, re-sharding each key according to
+// this window's own hash seed rather than assuming it matches whatever
+// window produced obsMap.
+func (w *window) restore(obsMap map[string]obs, winReqs int64) {
+	for i := range w.shards {
+		sh := &w.shards[i]
+		sh.mu.Lock()
+		sh.obs = make(map[string]*obs)
+		sh.cnt.Store(0)
+		sh.mu.Unlock()
+	}
+	for k, v := range obsMap {
+		v := v
+		sh := &w.shards[w.shardIndex(k)]
+		sh.mu.Lock()
+		sh.obs[k] = &v
+		sh.mu.Unlock()
+	}
+	// winReqs is a single window-wide count with no per-shard breakdown to
+	// restore it from; parking it on shard 0 is enough to make total()
+	// correct again, since record already treats the counter as sharded
+	// rather than per-key.
+	if len(w.shards) > 0 {
+		sh := &w.shards[0]
+		sh.mu.Lock()
+		sh.cnt.Store(winReqs)
+		sh.mu.Unlock()
+	}
+}
+
+// obsFor returns the tracked observation for key, if any. It exists to let
+// tests inspect per-key state without reaching into shard internals
+// directly.
+func (w *window) obsFor(key string) *obs {
+	sh := &w.shards[w.shardIndex(key)]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.obs[key]
+}
+
+// defaultShardCount picks a shard count proportional to available
+// parallelism, rounded up to a power of two.
+func defaultShardCount() int {
+	return nextPow2(runtime.GOMAXPROCS(0) * 4)
+}
+
+func nextPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
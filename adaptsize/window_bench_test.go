@@ -0,0 +1,27 @@
+package adaptsize
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// BenchmarkWindowRecordParallel drives concurrent record calls across
+// distinct keys per goroutine, to show throughput scaling with GOMAXPROCS
+// now that shards replace the single window mutex.
+func BenchmarkWindowRecordParallel(b *testing.B) {
+	for _, shards := range []int{1, defaultShardCount()} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			w := newWindow(shards)
+			var gid atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				prefix := fmt.Sprintf("p%d", gid.Add(1))
+				i := 0
+				for pb.Next() {
+					w.record(randKey(prefix, i), 4096, b.N+1)
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package adaptsize
+
+import (
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestNextPow2(t *testing.T) {
+	cases := map[int]int{0: 1, 1: 1, 2: 2, 3: 4, 4: 4, 5: 8, 17: 32}
+	for in, want := range cases {
+		if got := nextPow2(in); got != want {
+			t.Fatalf("nextPow2(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestWindowMatchesSingleMapImplementation(t *testing.T) {
+	r := rand.New(rand.NewPCG(99, 0))
+	keys := make([]string, 5000)
+	sizes := make([]int64, len(keys))
+	for i := range keys {
+		keys[i] = randKey("w", int(r.Int64N(500)))
+		sizes[i] = 1 + r.Int64N(1<<20)
+	}
+
+	// Reference: a single, unsharded map built the same way window used to
+	// be before sharding.
+	single := make(map[string]*obs)
+	for i, k := range keys {
+		o := single[k]
+		if o == nil {
+			o = &obs{}
+			single[k] = o
+		}
+		o.size = sizes[i]
+		o.cnt++
+	}
+
+	w := newWindow(16)
+	for i, k := range keys {
+		w.record(k, sizes[i], len(keys)+1) // windowN large enough to never fire
+	}
+	got, winReqs := w.peek()
+
+	if int(winReqs) != len(keys) {
+		t.Fatalf("expected winReqs=%d, got %d", len(keys), winReqs)
+	}
+	if len(got) != len(single) {
+		t.Fatalf("expected %d distinct keys, got %d", len(single), len(got))
+	}
+	for k, want := range single {
+		g, ok := got[k]
+		if !ok {
+			t.Fatalf("missing key %q in sharded window", k)
+		}
+		if g.size != want.size || g.cnt != want.cnt {
+			t.Fatalf("key %q: got {size:%d cnt:%d}, want {size:%d cnt:%d}", k, g.size, g.cnt, want.size, want.cnt)
+		}
+	}
+}
+
+func TestWindowSnapshotResetsAndFiresAtThreshold(t *testing.T) {
+	w := newWindow(4)
+	const windowN = 10
+	fired := false
+	for i := 0; i < windowN; i++ {
+		if w.record(randKey("k", i), 100, windowN) {
+			fired = true
+		}
+	}
+	if !fired {
+		t.Fatal("expected window to report full at windowN requests")
+	}
+	snap := w.snapshot()
+	if len(snap) != windowN {
+		t.Fatalf("expected %d entries in snapshot, got %d", windowN, len(snap))
+	}
+	snap2 := w.snapshot()
+	if len(snap2) != 0 {
+		t.Fatalf("expected window to be empty after snapshot, got %d entries", len(snap2))
+	}
+}
+
+func TestWindowConcurrentRecord(t *testing.T) {
+	w := newWindow(defaultShardCount())
+	var wg sync.WaitGroup
+	const goroutines, perG = 50, 200
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perG; j++ {
+				w.record(randKey("g", i*perG+j), 64, goroutines*perG+1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	_, winReqs := w.peek()
+	if winReqs != goroutines*perG {
+		t.Fatalf("expected winReqs=%d, got %d", goroutines*perG, winReqs)
+	}
+}